@@ -0,0 +1,384 @@
+package libimage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	dockerTransport "github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/reference"
+	dockerConfig "github.com/containers/image/v5/pkg/docker/config"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// referrersFallbackTagPrefix prefixes the tag under which PushArtifact
+// stores an artifact manifest when the registry does not implement the OCI
+// 1.1 /v2/<name>/referrers/<digest> API.  The tag is formed from the
+// artifact manifest's own digest; no referrers index is written under a tag
+// derived from the subject's digest, so clients that discover referrers
+// through such an index do not find the artifact.
+const referrersFallbackTagPrefix = "sha256-"
+
+// AttachmentSpec describes a single companion artifact (an SBOM, an
+// in-toto attestation, or any other supply-chain metadata blob) to push
+// alongside an image as an OCI 1.1 referrer of its manifest.
+type AttachmentSpec struct {
+	// LocalPath is the path to the artifact's contents on disk.
+	LocalPath string
+	// ArtifactType is the OCI artifactType/mediaType of the attachment,
+	// e.g. "application/spdx+json" for an SBOM or
+	// "application/vnd.in-toto+json" for an attestation.
+	ArtifactType string
+	// Annotations are recorded on the artifact manifest.
+	Annotations map[string]string
+}
+
+// PushArtifact pushes a single companion artifact to destination (which
+// must resolve to a docker-transport registry reference) as an OCI 1.1
+// referrer of the manifest identified by subject, linked via the artifact
+// manifest's Subject field.  It uploads the attachment's config and layer
+// blobs, then the artifact manifest itself: by digest when the registry
+// supports the /referrers API, and otherwise under the tag
+// "sha256-<hex of the artifact manifest's digest>" (see
+// referrersFallbackTagPrefix).
+func (r *Runtime) PushArtifact(ctx context.Context, destination string, subject digest.Digest, subjectSize int64, attachment AttachmentSpec) error {
+	named, err := reference.ParseNormalizedNamed(strings.TrimPrefix(destination, dockerTransport.Transport.Name()+"://"))
+	if err != nil {
+		return fmt.Errorf("parsing %q for attachment push: %w", destination, err)
+	}
+
+	content, err := os.ReadFile(attachment.LocalPath)
+	if err != nil {
+		return fmt.Errorf("reading attachment %q: %w", attachment.LocalPath, err)
+	}
+
+	client, err := newRegistryBlobClient(r.systemContextCopy(), named)
+	if err != nil {
+		return err
+	}
+
+	layerDesc, err := client.pushBlob(ctx, content, attachment.ArtifactType)
+	if err != nil {
+		return fmt.Errorf("pushing attachment blob: %w", err)
+	}
+
+	// Per the OCI artifact guidance, an empty JSON object is used as the
+	// config when the artifact has no meaningful config of its own.
+	emptyConfig := []byte("{}")
+	configDesc, err := client.pushBlob(ctx, emptyConfig, imgspecv1.MediaTypeImageConfig)
+	if err != nil {
+		return fmt.Errorf("pushing attachment config: %w", err)
+	}
+
+	artifactManifest := imgspecv1.Manifest{
+		MediaType:    imgspecv1.MediaTypeImageManifest,
+		ArtifactType: attachment.ArtifactType,
+		Config:       configDesc,
+		Layers:       []imgspecv1.Descriptor{layerDesc},
+		Subject: &imgspecv1.Descriptor{
+			MediaType: imgspecv1.MediaTypeImageManifest,
+			Digest:    subject,
+			Size:      subjectSize,
+		},
+		Annotations: attachment.Annotations,
+	}
+
+	manifestBytes, err := json.Marshal(artifactManifest)
+	if err != nil {
+		return fmt.Errorf("marshaling attachment manifest: %w", err)
+	}
+
+	manifestDigest := digest.FromBytes(manifestBytes)
+
+	supportsReferrers, err := client.supportsReferrersAPI(ctx, subject)
+	if err != nil {
+		logrus.Debugf("Checking /referrers support for %s: %v; assuming unsupported", named, err)
+		supportsReferrers = false
+	}
+
+	reference := manifestDigest.String()
+	if !supportsReferrers {
+		reference = referrersFallbackTagPrefix + manifestDigest.Encoded()
+	}
+
+	if err := client.pushManifest(ctx, reference, manifestBytes, imgspecv1.MediaTypeImageManifest); err != nil {
+		return fmt.Errorf("pushing attachment manifest: %w", err)
+	}
+
+	logrus.Debugf("Pushed attachment %s (%s) as referrer %s of %s", attachment.LocalPath, attachment.ArtifactType, manifestDigest, subject)
+
+	return nil
+}
+
+// pushAttachments uploads each configured attachment after the main image
+// identified by manifestDigest/manifestSize has been pushed to destination.
+func (r *Runtime) pushAttachments(ctx context.Context, destination string, manifestDigest digest.Digest, manifestSize int64, attachments []AttachmentSpec) error {
+	for _, attachment := range attachments {
+		if err := r.PushArtifact(ctx, destination, manifestDigest, manifestSize, attachment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registryBlobClient performs the minimal set of raw registry API calls
+// needed to push OCI artifact blobs and manifests that are not otherwise
+// exposed by containers/image's higher-level copy APIs.  It always speaks
+// HTTPS, honors SystemContext.DockerInsecureSkipTLSVerify and uses the
+// registry credentials found by pkg/docker/config; other TLS settings, such
+// as certificate directories, are not applied.
+type registryBlobClient struct {
+	httpClient *http.Client
+	registry   string
+	repository string
+	username   string
+	password   string
+}
+
+func newRegistryBlobClient(sys *types.SystemContext, named reference.Named) (*registryBlobClient, error) {
+	registry := reference.Domain(named)
+
+	transport := &http.Transport{}
+	if sys != nil && sys.DockerInsecureSkipTLSVerify == types.OptionalBoolTrue {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec // explicit opt-in via SystemContext
+	}
+
+	username, password := "", ""
+	if authConfig, err := dockerConfig.GetCredentials(sys, registry); err != nil {
+		logrus.Debugf("Looking up credentials for %s: %v; pushing attachments unauthenticated", registry, err)
+	} else {
+		username, password = authConfig.Username, authConfig.Password
+	}
+
+	return &registryBlobClient{
+		httpClient: &http.Client{Transport: transport},
+		registry:   registry,
+		repository: reference.Path(named),
+		username:   username,
+		password:   password,
+	}, nil
+}
+
+// authenticate attaches the registry credentials resolved at client
+// construction time, if any, to req.  This alone is sufficient for
+// registries that accept HTTP Basic auth directly; doAuthenticated handles
+// the Bearer-token challenge flow required by most public registries.
+func (c *registryBlobClient) authenticate(req *http.Request) {
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+}
+
+// doAuthenticated performs req, transparently handling the Docker Registry
+// v2 Bearer-token challenge flow used by Docker Hub, GHCR, Quay, ECR, GCR,
+// and most other registries: if the first attempt comes back 401 with a
+// WWW-Authenticate: Bearer challenge, it exchanges c's credentials for a
+// bearer token at the challenge's realm and retries the request once with
+// that token.
+func (c *registryBlobClient) doAuthenticated(req *http.Request) (*http.Response, error) {
+	c.authenticate(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	token, err := c.fetchBearerToken(req.Context(), challenge)
+	if err != nil {
+		return nil, fmt.Errorf("negotiating bearer token for %s: %w", req.URL, err)
+	}
+
+	retryReq := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retryReq.Body = body
+	}
+	retryReq.Header.Set("Authorization", "Bearer "+token)
+
+	return c.httpClient.Do(retryReq)
+}
+
+// fetchBearerToken exchanges c's credentials for a bearer token at the
+// realm/service/scope advertised by a WWW-Authenticate: Bearer challenge,
+// per the Docker Registry v2 token authentication spec.
+func (c *registryBlobClient) fetchBearerToken(ctx context.Context, challenge string) (string, error) {
+	params := parseBearerChallenge(challenge)
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("challenge %q carries no realm", challenge)
+	}
+
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("parsing realm %q: %w", realm, err)
+	}
+	query := tokenURL.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	if scope := params["scope"]; scope != "" {
+		query.Set("scope", scope)
+	}
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("unexpected status %d fetching token: %s", resp.StatusCode, body)
+	}
+
+	// The token spec calls the field "token"; older registries (notably
+	// Docker Hub's original implementation) instead call it
+	// "access_token". Accept either.
+	var tokenResp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("decoding token response: %w", err)
+	}
+	if tokenResp.Token != "" {
+		return tokenResp.Token, nil
+	}
+	return tokenResp.AccessToken, nil
+}
+
+// parseBearerChallenge extracts the realm/service/scope parameters from a
+// WWW-Authenticate: Bearer ... challenge header. It splits on commas
+// outside of quoted values, since a scope such as
+// `repository:foo/bar:pull,push` legitimately contains one.
+func parseBearerChallenge(challenge string) map[string]string {
+	params := make(map[string]string)
+	challenge = strings.TrimPrefix(challenge, "Bearer ")
+
+	var key, value strings.Builder
+	inValue, inQuotes := false, false
+	flush := func() {
+		if k := strings.TrimSpace(key.String()); k != "" {
+			params[k] = value.String()
+		}
+		key.Reset()
+		value.Reset()
+		inValue = false
+	}
+	for _, r := range challenge {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == '=' && !inValue:
+			inValue = true
+		case r == ',' && !inQuotes:
+			flush()
+		case inValue:
+			value.WriteRune(r)
+		default:
+			key.WriteRune(r)
+		}
+	}
+	flush()
+	return params
+}
+
+func (c *registryBlobClient) manifestURL(reference string) string {
+	return fmt.Sprintf("https://%s/v2/%s/manifests/%s", c.registry, c.repository, reference)
+}
+
+// pushBlob uploads content as a monolithic blob and returns its descriptor.
+func (c *registryBlobClient) pushBlob(ctx context.Context, content []byte, mediaType string) (imgspecv1.Descriptor, error) {
+	sum := sha256.Sum256(content)
+	dgst := digest.NewDigestFromEncoded(digest.SHA256, hex.EncodeToString(sum[:]))
+
+	uploadURL := fmt.Sprintf("https://%s/v2/%s/blobs/uploads/?digest=%s", c.registry, c.repository, dgst)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, bytes.NewReader(content))
+	if err != nil {
+		return imgspecv1.Descriptor{}, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := c.doAuthenticated(req)
+	if err != nil {
+		return imgspecv1.Descriptor{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return imgspecv1.Descriptor{}, fmt.Errorf("unexpected status %d uploading blob: %s", resp.StatusCode, body)
+	}
+
+	return imgspecv1.Descriptor{
+		MediaType: mediaType,
+		Digest:    dgst,
+		Size:      int64(len(content)),
+	}, nil
+}
+
+func (c *registryBlobClient) pushManifest(ctx context.Context, reference string, manifestBytes []byte, mediaType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.manifestURL(reference), bytes.NewReader(manifestBytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mediaType)
+
+	resp, err := c.doAuthenticated(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %d pushing manifest: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// supportsReferrersAPI probes whether the registry implements the OCI 1.1
+// GET /v2/<name>/referrers/<digest> endpoint for the manifest identified by
+// subject.
+func (c *registryBlobClient) supportsReferrersAPI(ctx context.Context, subject digest.Digest) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https://%s/v2/%s/referrers/%s", c.registry, c.repository, subject), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.doAuthenticated(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode != http.StatusNotFound, nil
+}
@@ -0,0 +1,33 @@
+package libimage
+
+import "testing"
+
+func TestParseBearerChallenge(t *testing.T) {
+	challenge := `Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:foo/bar:pull,push"`
+
+	params := parseBearerChallenge(challenge)
+
+	want := map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:foo/bar:pull,push",
+	}
+	for k, v := range want {
+		if got := params[k]; got != v {
+			t.Errorf("parseBearerChallenge(...)[%q] = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestParseBearerChallengeScopeWithComma(t *testing.T) {
+	// The scope value itself may contain a comma-separated action list
+	// (e.g. "pull,push"); that comma must not be mistaken for a
+	// parameter separator.
+	challenge := `Bearer realm="https://auth.example.com/token",scope="repository:foo/bar:pull,push"`
+
+	params := parseBearerChallenge(challenge)
+
+	if got, want := params["scope"], "repository:foo/bar:pull,push"; got != want {
+		t.Errorf("parseBearerChallenge(...)[\"scope\"] = %q, want %q", got, want)
+	}
+}
@@ -4,19 +4,95 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/containers/common/pkg/retry"
 	dockerTransport "github.com/containers/image/v5/docker"
 	dockerArchiveTransport "github.com/containers/image/v5/docker/archive"
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/transports/alltransports"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 )
 
 // PushOptions allows for custommizing image pushes.
 type PushOptions struct {
 	CopyOptions
 	AllTags bool
+
+	// Sources is an optional list of additional image names/IDs in the
+	// local containers storage to push alongside source.  When set
+	// together with a docker-archive destination, all of the named
+	// images are written into the very same tar file instead of one
+	// archive per image.  Otherwise, each source is pushed under its own
+	// tag beneath destination's repository, subject to MaxParallelPushes.
+	Sources []string
+
+	// MaxParallelPushes bounds how many of the per-tag pushes triggered
+	// by AllTags or Sources run concurrently.  Defaults to 1, i.e.
+	// sequential pushes, to preserve prior behavior.
+	MaxParallelPushes int
+
+	// ContinueOnError makes a multi-tag push (AllTags or Sources)
+	// collect per-tag errors in PushReport.Errors instead of aborting
+	// the remaining pushes on the first failure.
+	ContinueOnError bool
+
+	// RetryOptions, if set, transparently retries a push on transient
+	// registry failures (5xx, connection resets, EOF, TLS handshake
+	// errors, and throttling) using exponential backoff.  This is
+	// especially useful combined with AllTags/Sources, where a single
+	// flaky tag would otherwise abort the whole batch.  Nil disables
+	// retries.  Reuses the same pkg/retry machinery as PullOptions.
+	RetryOptions *retry.Options
+
+	// SignBySigstorePrivateKeyFile, if non-empty, asks for a sigstore
+	// signature of the pushed image to be created using the private key
+	// at the specified path, in addition to any GPG signature requested
+	// via CopyOptions.SignBy.
+	SignBySigstorePrivateKeyFile string
+	// SignSigstorePassphraseFile, if non-empty, points to a file whose
+	// contents are the passphrase protecting SignBySigstorePrivateKeyFile.
+	SignSigstorePassphraseFile string
+	// SignBySigstoreParamFile, if non-empty, points to a parameter file
+	// configuring a non-private-key sigstore signing flow (e.g. Fulcio/
+	// OIDC keyless signing), mirroring `cosign sign --fulcio-url`.
+	SignBySigstoreParamFile string
+	// SigstoreRekorURL, if non-empty, is the transparency log the
+	// sigstore signature is additionally uploaded to.
+	SigstoreRekorURL string
+
+	// Attachments are companion artifacts (SBOMs, attestations, or
+	// arbitrary blobs) pushed as OCI 1.1 referrers of the image once it
+	// has been pushed.  Only supported when destination is a docker
+	// transport registry reference.
+	Attachments []AttachmentSpec
+
+	// SourceTransport, if non-empty, makes source be resolved as an
+	// image reference under this transport (e.g. "docker") instead of
+	// being looked up in the local containers storage.  This lets Push
+	// mirror an image straight from one registry to another without
+	// restaging it locally; see also Runtime.Copy and Runtime.Replicate
+	// for the same capability with an arbitrary source transport and
+	// multi-tag replication.
+	SourceTransport string
+}
+
+// PushReport is returned by PushMany and aggregates the results of pushing
+// an image to more than one destination, e.g. via PushOptions.AllTags or
+// PushOptions.Sources.
+type PushReport struct {
+	// ManifestBytes maps each successfully pushed destination to the
+	// manifest bytes returned for it.
+	ManifestBytes map[string][]byte
+	// Errors maps each destination that failed to push to the error
+	// that occurred.  Only populated when ContinueOnError is set;
+	// otherwise the first error aborts the remaining pushes and is
+	// returned directly.
+	Errors map[string]error
 }
 
 // Push pushes the specified source which must refer to an image in the local
@@ -33,6 +109,13 @@ func (r *Runtime) Push(ctx context.Context, source, destination string, options
 		options = &PushOptions{}
 	}
 
+	// A non-empty SourceTransport means source lives outside the local
+	// containers storage (e.g. another registry); hand off to Copy
+	// instead of looking it up locally.
+	if options.SourceTransport != "" {
+		return r.Copy(ctx, options.SourceTransport+"://"+source, destination, options)
+	}
+
 	// Look up the local image.  Note that we need to ignore the platform
 	// and push what the user specified (containers/podman/issues/10344).
 	image, resolvedSource, err := r.LookupImage(source, nil)
@@ -65,13 +148,66 @@ func (r *Runtime) Push(ctx context.Context, source, destination string, options
 
 		logrus.Debugf("Flag --all-tags true, found: %s", namedRepoTags)
 
+		fullNamedTags := make([]string, 0, len(namedRepoTags))
 		for _, tag := range namedRepoTags {
-			fullNamedTag := fmt.Sprintf("%s:%s", destination, tag.Tag())
-			_, err = pushImage(ctx, fullNamedTag, options, image, r)
+			fullNamedTags = append(fullNamedTags, fmt.Sprintf("%s:%s", destination, tag.Tag()))
+		}
+
+		images := make([]*Image, len(fullNamedTags))
+		for i := range fullNamedTags {
+			images[i] = image
+		}
+
+		if isDockerArchiveDestination(destination) {
+			return r.pushManyToDockerArchive(ctx, destination, images, namedRepoTags, options)
+		}
+
+		report, err := r.PushMany(ctx, images, fullNamedTags, options)
+		if err != nil {
+			return nil, err
+		}
+		return lastManifestBytes(fullNamedTags, report), nil
+	} else if len(options.Sources) > 0 && isDockerArchiveDestination(destination) {
+		// Multiple source images sharing a single docker-archive tar
+		// file; mirrors how Runtime.SaveImages writes a multi-image
+		// archive.
+		images := make([]*Image, 0, len(options.Sources)+1)
+		images = append(images, image)
+		taggedRefs := make([]reference.NamedTagged, 0, len(options.Sources)+1)
+		taggedRefs = append(taggedRefs, parseTaggedRef(resolvedSource))
+
+		for _, extra := range options.Sources {
+			extraImage, resolvedExtra, err := r.LookupImage(extra, nil)
 			if err != nil {
 				return nil, err
 			}
+			images = append(images, extraImage)
+			taggedRefs = append(taggedRefs, parseTaggedRef(resolvedExtra))
+		}
+
+		return r.pushManyToDockerArchive(ctx, destination, images, taggedRefs, options)
+	} else if len(options.Sources) > 0 {
+		// Push the looked-up source plus every additional source, each
+		// under its own tag beneath destination, in parallel.
+		images := make([]*Image, 0, len(options.Sources)+1)
+		images = append(images, image)
+		destinations := make([]string, 0, len(options.Sources)+1)
+		destinations = append(destinations, destination)
+
+		for _, extra := range options.Sources {
+			extraImage, resolvedExtra, err := r.LookupImage(extra, nil)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, extraImage)
+			destinations = append(destinations, tagUnderDestination(destination, resolvedExtra, extraImage))
+		}
+
+		report, err := r.PushMany(ctx, images, destinations, options)
+		if err != nil {
+			return nil, err
 		}
+		return lastManifestBytes(destinations, report), nil
 	} else {
 		// No --all-tags, so just push just the single image.
 		return pushImage(ctx, destination, options, image, r)
@@ -80,12 +216,211 @@ func (r *Runtime) Push(ctx context.Context, source, destination string, options
 	return nil, nil
 }
 
+// isDockerArchiveDestination returns true if destination refers to the
+// docker-archive transport.
+func isDockerArchiveDestination(destination string) bool {
+	return strings.HasPrefix(destination, dockerArchiveTransport.Transport.Name()+":")
+}
+
+// tagUnderDestination derives the full destination for an additional
+// PushOptions.Sources entry: the tag portion of its own resolved name,
+// appended to destination, so that every source ends up in the repository
+// the caller actually asked to push to rather than wherever its local tag
+// happens to point.  Falls back to the image's short ID when resolvedSource
+// carries no tag.
+func tagUnderDestination(destination, resolvedSource string, image *Image) string {
+	if named, err := reference.ParseNormalizedNamed(resolvedSource); err == nil {
+		if tagged, ok := named.(reference.NamedTagged); ok {
+			return fmt.Sprintf("%s:%s", destination, tagged.Tag())
+		}
+	}
+	return fmt.Sprintf("%s:%s", destination, image.ID()[:12])
+}
+
+// parseTaggedRef parses resolvedName (a fully resolved source name as
+// returned by LookupImage) and returns the tag it carries, for annotating
+// the corresponding image written into a docker-archive.  Returns nil if
+// resolvedName doesn't parse or carries no tag, in which case the image is
+// written to the archive untagged.
+func parseTaggedRef(resolvedName string) reference.NamedTagged {
+	named, err := reference.ParseNormalizedNamed(resolvedName)
+	if err != nil {
+		return nil
+	}
+	tagged, ok := named.(reference.NamedTagged)
+	if !ok {
+		return nil
+	}
+	return tagged
+}
+
+// lastManifestBytes returns the manifest bytes of the last destination in
+// destinations that was pushed successfully, or nil if none were.  It lets
+// callers of PushMany keep returning a single []byte for backward
+// compatibility with Push's signature.
+func lastManifestBytes(destinations []string, report *PushReport) []byte {
+	for i := len(destinations) - 1; i >= 0; i-- {
+		if manifestBytes, ok := report.ManifestBytes[destinations[i]]; ok {
+			return manifestBytes
+		}
+	}
+	return nil
+}
+
+// PushMany concurrently pushes each of images to the corresponding entry in
+// destinations, bounded by options.MaxParallelPushes (default 1).  It is
+// used internally by Push for AllTags and Sources, and can also be called
+// directly by callers that already have a resolved image/destination list
+// (e.g. registry-mirroring tools).
+//
+// Unless options.ContinueOnError is set, the first hard failure cancels the
+// remaining pushes and is returned as the error; otherwise all pushes run to
+// completion and failures are collected in PushReport.Errors.
+func (r *Runtime) PushMany(ctx context.Context, images []*Image, destinations []string, options *PushOptions) (*PushReport, error) {
+	if options == nil {
+		options = &PushOptions{}
+	}
+	if len(images) != len(destinations) {
+		return nil, fmt.Errorf("internal error: %d images and %d destinations", len(images), len(destinations))
+	}
+
+	maxParallel := options.MaxParallelPushes
+	if maxParallel < 1 {
+		maxParallel = 1
+	}
+
+	report := &PushReport{ManifestBytes: make(map[string][]byte, len(destinations))}
+	var mu sync.Mutex
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, maxParallel)
+
+	for i := range destinations {
+		dest := destinations[i]
+		image := images[i]
+
+		eg.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// pushImage/pushRef mutate the options they're given
+			// (e.g. dockerArchiveAdditionalTags, sigstore fields),
+			// so each concurrent push needs its own copy rather
+			// than sharing the caller's *PushOptions.
+			perPushOptions := *options
+			manifestBytes, err := pushImage(egCtx, dest, &perPushOptions, image, r)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				if !options.ContinueOnError {
+					return fmt.Errorf("pushing %s: %w", dest, err)
+				}
+				if report.Errors == nil {
+					report.Errors = make(map[string]error)
+				}
+				report.Errors[dest] = err
+				return nil
+			}
+
+			report.ManifestBytes[dest] = manifestBytes
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// pushManyToDockerArchive pushes images into a single docker-archive tar
+// file, using one archive.Writer for all of them so that the resulting tar
+// is a portable, multi-image archive that `docker load` can consume with
+// all tags preserved.  taggedRefs must be parallel to images; a nil entry
+// means the corresponding image is written without a tag annotation.
+func (r *Runtime) pushManyToDockerArchive(ctx context.Context, destination string, images []*Image, taggedRefs []reference.NamedTagged, options *PushOptions) ([]byte, error) {
+	path := strings.TrimPrefix(destination, dockerArchiveTransport.Transport.Name()+":")
+	// A docker-archive destination may carry an optional
+	// ":docker-reference" suffix; strip it off since each image gets its
+	// own reference from the writer below.
+	if idx := strings.Index(path, ":"); idx != -1 {
+		path = path[:idx]
+	}
+
+	writer, err := dockerArchiveTransport.NewWriter(r.systemContextCopy(), path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := writer.Close(); err != nil {
+			logrus.Errorf("Closing docker-archive writer for %q: %v", path, err)
+		}
+	}()
+
+	var manifestBytes []byte
+	for i, image := range images {
+		var perImageTags []reference.NamedTagged
+		if tagged := taggedRefs[i]; tagged != nil {
+			perImageTags = []reference.NamedTagged{tagged}
+		}
+
+		destRef, err := writer.NewReference(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		srcRef, err := image.StorageReference()
+		if err != nil {
+			return nil, err
+		}
+
+		logrus.Debugf("Pushing image %s to docker-archive writer %s (tags: %v)", srcRef, path, perImageTags)
+
+		archiveOptions := *options
+		archiveOptions.dockerArchiveAdditionalTags = perImageTags
+		applySigstoreOptions(&archiveOptions)
+
+		if r.eventChannel != nil {
+			defer r.writeEvent(&Event{ID: image.ID(), Name: destination, Time: time.Now(), Type: EventTypeImagePush})
+		}
+
+		c, err := r.newCopier(&archiveOptions.CopyOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		err = retryCopy(ctx, archiveOptions.RetryOptions, func() error {
+			var copyErr error
+			manifestBytes, copyErr = c.copy(ctx, srcRef, destRef)
+			return copyErr
+		})
+		c.close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return manifestBytes, nil
+}
+
 func pushImage(ctx context.Context, destination string, options *PushOptions, image *Image, r *Runtime) ([]byte, error) {
 	srcRef, err := image.StorageReference()
 	if err != nil {
 		return nil, err
 	}
 
+	return r.pushRef(ctx, srcRef, destination, options, image)
+}
+
+// pushRef copies srcRef to destination.  image is the local-storage image
+// that srcRef was obtained from, or nil when srcRef refers to an arbitrary
+// (e.g. remote) source, as used by Runtime.Copy; a nil image skips the
+// push and sign events, which carry a local image ID.  The --all-tags
+// registry-only check applies whether or not image is nil.
+func (r *Runtime) pushRef(ctx context.Context, srcRef types.ImageReference, destination string, options *PushOptions, image *Image) ([]byte, error) {
 	logrus.Debugf("Pushing image %s to %s", srcRef, destination)
 
 	destRef, err := alltransports.ParseImageName(destination)
@@ -104,7 +439,7 @@ func pushImage(ctx context.Context, destination string, options *PushOptions, im
 		return nil, fmt.Errorf("--all-tags can only be used with docker transport")
 	}
 
-	if r.eventChannel != nil {
+	if r.eventChannel != nil && image != nil {
 		defer r.writeEvent(&Event{ID: image.ID(), Name: destination, Time: time.Now(), Type: EventTypeImagePush})
 	}
 
@@ -119,6 +454,8 @@ func pushImage(ctx context.Context, destination string, options *PushOptions, im
 		}
 	}
 
+	applySigstoreOptions(options)
+
 	c, err := r.newCopier(&options.CopyOptions)
 	if err != nil {
 		return nil, err
@@ -126,5 +463,53 @@ func pushImage(ctx context.Context, destination string, options *PushOptions, im
 
 	defer c.close()
 
-	return c.copy(ctx, srcRef, destRef)
+	var manifestBytes []byte
+	err = retryCopy(ctx, options.RetryOptions, func() error {
+		var copyErr error
+		manifestBytes, copyErr = c.copy(ctx, srcRef, destRef)
+		return copyErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	signed := options.SignBySigstorePrivateKeyFile != "" || options.SignBySigstoreParamFile != ""
+	if r.eventChannel != nil && image != nil && signed {
+		r.writeEvent(&Event{ID: image.ID(), Name: destination, Time: time.Now(), Type: EventTypeImageSign})
+	}
+
+	if len(options.Attachments) > 0 && destRef.Transport().Name() == dockerTransport.Transport.Name() {
+		manifestDigest := digest.FromBytes(manifestBytes)
+		if err := r.pushAttachments(ctx, destination, manifestDigest, int64(len(manifestBytes)), options.Attachments); err != nil {
+			return manifestBytes, err
+		}
+	}
+
+	return manifestBytes, nil
+}
+
+// applySigstoreOptions copies the push-level sigstore signing configuration
+// onto the embedded CopyOptions so that r.newCopier picks it up the same way
+// it already does for GPG signing via CopyOptions.SignBy.
+func applySigstoreOptions(options *PushOptions) {
+	if options.SignBySigstorePrivateKeyFile != "" {
+		options.CopyOptions.SignBySigstorePrivateKeyFile = options.SignBySigstorePrivateKeyFile
+		options.CopyOptions.SignSigstorePrivateKeyPassphraseFile = options.SignSigstorePassphraseFile
+	}
+	if options.SignBySigstoreParamFile != "" {
+		options.CopyOptions.SignSigstoreParamFile = options.SignBySigstoreParamFile
+	}
+	if options.SigstoreRekorURL != "" {
+		options.CopyOptions.SigstoreRekorURL = options.SigstoreRekorURL
+	}
+}
+
+// retryCopy runs operation, retrying it according to opts on transient
+// registry failures.  A nil opts disables retries, preserving the previous
+// behavior of a single, unretried attempt.
+func retryCopy(ctx context.Context, opts *retry.Options, operation func() error) error {
+	if opts == nil {
+		return operation()
+	}
+	return retry.RetryIfNecessary(ctx, operation, opts)
 }
@@ -0,0 +1,116 @@
+package libimage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestIsDockerArchiveDestination(t *testing.T) {
+	tests := []struct {
+		destination string
+		want        bool
+	}{
+		{"docker-archive:/tmp/foo.tar", true},
+		{"docker-archive:/tmp/foo.tar:repo:tag", true},
+		{"docker://registry.example.com/repo:tag", false},
+		{"registry.example.com/repo:tag", false},
+		{"oci-archive:/tmp/foo.tar", false},
+	}
+
+	for _, tt := range tests {
+		if got := isDockerArchiveDestination(tt.destination); got != tt.want {
+			t.Errorf("isDockerArchiveDestination(%q) = %v, want %v", tt.destination, got, tt.want)
+		}
+	}
+}
+
+func TestLastManifestBytes(t *testing.T) {
+	report := &PushReport{
+		ManifestBytes: map[string][]byte{
+			"dst:a": []byte("manifest-a"),
+			"dst:c": []byte("manifest-c"),
+		},
+	}
+
+	tests := []struct {
+		name         string
+		destinations []string
+		want         string
+	}{
+		{"last succeeded", []string{"dst:a", "dst:b", "dst:c"}, "manifest-c"},
+		{"only earlier succeeded", []string{"dst:a", "dst:b"}, "manifest-a"},
+		{"none succeeded", []string{"dst:x", "dst:y"}, ""},
+		{"empty", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lastManifestBytes(tt.destinations, report)
+			if string(got) != tt.want {
+				t.Errorf("lastManifestBytes(%v) = %q, want %q", tt.destinations, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTaggedRef(t *testing.T) {
+	tests := []struct {
+		resolvedName string
+		wantTag      string
+		wantNil      bool
+	}{
+		{"registry.example.com/repo:v1", "v1", false},
+		{"registry.example.com/repo", "", true},
+		{"not a valid reference!!", "", true},
+	}
+
+	for _, tt := range tests {
+		got := parseTaggedRef(tt.resolvedName)
+		if tt.wantNil {
+			if got != nil {
+				t.Errorf("parseTaggedRef(%q) = %v, want nil", tt.resolvedName, got)
+			}
+			continue
+		}
+		if got == nil {
+			t.Fatalf("parseTaggedRef(%q) = nil, want tag %q", tt.resolvedName, tt.wantTag)
+		}
+		if got.Tag() != tt.wantTag {
+			t.Errorf("parseTaggedRef(%q).Tag() = %q, want %q", tt.resolvedName, got.Tag(), tt.wantTag)
+		}
+	}
+}
+
+func TestRetryCopyNilOptsRunsOnce(t *testing.T) {
+	calls := 0
+	err := retryCopy(context.Background(), nil, func() error {
+		calls++
+		return errors.New("boom")
+	})
+	if err == nil {
+		t.Fatal("retryCopy(nil opts) = nil error, want the operation's error")
+	}
+	if calls != 1 {
+		t.Errorf("retryCopy(nil opts) called operation %d times, want 1 (no retries)", calls)
+	}
+}
+
+func TestTagUnderDestinationWithTag(t *testing.T) {
+	tests := []struct {
+		destination    string
+		resolvedSource string
+		want           string
+	}{
+		{"registry.example.com/repo", "other.example.com/foo:v1", "registry.example.com/repo:v1"},
+		{"registry.example.com/repo", "registry.example.com/repo2:latest", "registry.example.com/repo:latest"},
+	}
+
+	for _, tt := range tests {
+		// The image argument is only consulted on the untagged fallback
+		// path, so it's safe to pass nil here.
+		if got := tagUnderDestination(tt.destination, tt.resolvedSource, nil); got != tt.want {
+			t.Errorf("tagUnderDestination(%q, %q, nil) = %q, want %q", tt.destination, tt.resolvedSource, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package libimage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/transports/alltransports"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// Copy copies source directly to destination without first pulling source
+// into the local containers storage, e.g.
+// docker://src.example/foo:tag -> docker://dst.example/foo:tag.  Both source
+// and destination may refer to any transport supported by
+// alltransports.ParseImageName; if destination carries no transport prefix,
+// the docker transport is assumed, mirroring Push.  options is the same
+// PushOptions used by Push, so RetryOptions, sigstore signing, and
+// Attachments all apply here too.
+func (r *Runtime) Copy(ctx context.Context, source, destination string, options *PushOptions) ([]byte, error) {
+	if options == nil {
+		options = &PushOptions{}
+	}
+
+	srcRef, err := alltransports.ParseImageName(source)
+	if err != nil {
+		return nil, fmt.Errorf("parsing source %q: %w", source, err)
+	}
+
+	return r.pushRef(ctx, srcRef, destination, options, nil)
+}
+
+// ReplicateOptions configures Runtime.Replicate.  It embeds PushOptions so
+// that RetryOptions and friends are available to a replication the same way
+// they are to a single Copy; AllTags is reinterpreted as "replicate every
+// tag" rather than "push every local tag".
+type ReplicateOptions struct {
+	PushOptions
+}
+
+// Replicate copies source to destination the same way Copy does, except
+// that when options.AllTags is set it first queries the source registry's
+// /v2/<name>/tags/list and replicates every tag it finds, reusing the same
+// copier, policy, and retry plumbing (including options.RetryOptions) as a
+// single-reference Copy.  The per-tag replications are bounded by
+// options.MaxParallelPushes (default 1) and, like PushMany, honor
+// options.ContinueOnError by collecting per-tag failures in
+// PushReport.Errors instead of aborting the rest of the tags.  This makes
+// Replicate usable as the engine behind a skopeo sync-style registry
+// mirroring tool.
+func (r *Runtime) Replicate(ctx context.Context, source, destination string, options *ReplicateOptions) (*PushReport, error) {
+	if options == nil {
+		options = &ReplicateOptions{}
+	}
+
+	if !options.AllTags {
+		manifestBytes, err := r.Copy(ctx, source, destination, &options.PushOptions)
+		if err != nil {
+			return nil, err
+		}
+		return &PushReport{ManifestBytes: map[string][]byte{destination: manifestBytes}}, nil
+	}
+
+	srcNamed, err := parseDockerRepository(source)
+	if err != nil {
+		return nil, fmt.Errorf("parsing source %q for --all-tags replication: %w", source, err)
+	}
+	dstNamed, err := parseDockerRepository(destination)
+	if err != nil {
+		return nil, fmt.Errorf("parsing destination %q for --all-tags replication: %w", destination, err)
+	}
+
+	srcRef, err := docker.NewReference(reference.TagNameOnly(srcNamed))
+	if err != nil {
+		return nil, err
+	}
+	tags, err := docker.GetRepositoryTags(ctx, r.systemContextCopy(), srcRef)
+	if err != nil {
+		return nil, fmt.Errorf("listing tags for %q: %w", source, err)
+	}
+
+	logrus.Debugf("Replicate --all-tags found %d tags for %q", len(tags), source)
+
+	maxParallel := options.MaxParallelPushes
+	if maxParallel < 1 {
+		maxParallel = 1
+	}
+
+	report := &PushReport{ManifestBytes: make(map[string][]byte, len(tags))}
+	var mu sync.Mutex
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, maxParallel)
+
+	for _, tag := range tags {
+		srcTag := fmt.Sprintf("docker://%s:%s", srcNamed.Name(), tag)
+		dstTag := fmt.Sprintf("docker://%s:%s", dstNamed.Name(), tag)
+
+		eg.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// Copy/pushRef mutate the options they're given, so each
+			// concurrent replication needs its own copy rather than
+			// sharing options.PushOptions, the same as PushMany.
+			perPushOptions := options.PushOptions
+			manifestBytes, err := r.Copy(egCtx, srcTag, dstTag, &perPushOptions)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				wrapped := fmt.Errorf("replicating tag %q: %w", tag, err)
+				if !options.ContinueOnError {
+					return wrapped
+				}
+				if report.Errors == nil {
+					report.Errors = make(map[string]error)
+				}
+				report.Errors[dstTag] = wrapped
+				return nil
+			}
+
+			report.ManifestBytes[dstTag] = manifestBytes
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// parseDockerRepository strips an optional "docker://" prefix from ref and
+// parses the remainder as a (possibly tagged) docker reference.
+func parseDockerRepository(ref string) (reference.Named, error) {
+	return reference.ParseNormalizedNamed(strings.TrimPrefix(ref, "docker://"))
+}
@@ -0,0 +1,28 @@
+package libimage
+
+import "testing"
+
+func TestParseDockerRepository(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"docker://registry.example.com/repo:tag", "registry.example.com/repo"},
+		{"registry.example.com/repo", "registry.example.com/repo"},
+		{"docker://registry.example.com/repo", "registry.example.com/repo"},
+	}
+
+	for _, tt := range tests {
+		named, err := parseDockerRepository(tt.ref)
+		if err != nil {
+			t.Fatalf("parseDockerRepository(%q) returned error: %v", tt.ref, err)
+		}
+		if named.Name() != tt.want {
+			t.Errorf("parseDockerRepository(%q).Name() = %q, want %q", tt.ref, named.Name(), tt.want)
+		}
+	}
+
+	if _, err := parseDockerRepository("not a valid reference!!"); err == nil {
+		t.Error("parseDockerRepository(invalid) = nil error, want error")
+	}
+}